@@ -0,0 +1,330 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/procfs"
+)
+
+// metricCollector is implemented by types that can gather per-process
+// statistics for every process found under a given /proc path. It is the
+// seam that lets tests substitute a fake without touching the real file
+// system.
+type metricCollector interface {
+	GetStats(procPath string) ([]Proc, error)
+}
+
+// procStatsCollector is the default metricCollector. It reads /proc through
+// github.com/prometheus/procfs instead of parsing "stat" field indexes by
+// hand.
+type procStatsCollector struct{}
+
+// ProcIO holds the per-process I/O counters reported in /proc/<pid>/io.
+type ProcIO struct {
+	RChar      uint64
+	WChar      uint64
+	SyscR      uint64
+	SyscW      uint64
+	ReadBytes  uint64
+	WriteBytes uint64
+}
+
+// Proc is a strongly-typed view of a single process, assembled from
+// /proc/<pid>/stat, /proc/<pid>/io and /proc/<pid>/limits. Replacing the old
+// raw field-index lookups with named fields removes a whole class of
+// off-by-one bugs when the kernel adds new stat fields.
+type Proc struct {
+	Pid        int
+	Cmd        string
+	CmdLine    string
+	State      string
+	VSize      uint64
+	RSS        uint64
+	MinFlt     uint64
+	MajFlt     uint64
+	UTime      uint64
+	STime      uint64
+	Data       uint64
+	Code       uint64
+	StackSize  uint64
+	StartTime  uint64
+	NumThreads int64
+	NumFDs     int
+	UID        uint32
+	GID        uint32
+	IO         ProcIO
+	Limits     procfs.ProcLimits
+
+	// StartTimeUnix is StartTime converted to a wall-clock unix timestamp
+	// using the system boot time.
+	StartTimeUnix int64
+
+	// CapEff is the effective capability mask (CapEff in /proc/<pid>/status)
+	// as a hex string, e.g. "0000003fffffffff".
+	CapEff string
+	// SeccompMode is 0 (disabled), 1 (strict) or 2 (filter).
+	SeccompMode int
+	// OomScore and OomScoreAdj come from /proc/<pid>/oom_score and
+	// /proc/<pid>/oom_score_adj.
+	OomScore    int
+	OomScoreAdj int
+	// Cgroup is the process's unified (cgroup v2) cgroup path, or the path
+	// of its first listed hierarchy on a cgroup v1 system.
+	Cgroup string
+	// SELinuxLabel is the contents of /proc/<pid>/attr/current, if present.
+	SELinuxLabel string
+}
+
+// GetStats returns a Proc for every process currently visible under
+// procPath.
+func (c *procStatsCollector) GetStats(procPath string) ([]Proc, error) {
+	fs, err := procfs.NewFS(procPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open proc filesystem %q: %v", procPath, err)
+	}
+
+	procs, err := fs.AllProcs()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list processes under %q: %v", procPath, err)
+	}
+
+	var bootTime uint64
+	if kstat, err := fs.Stat(); err == nil {
+		bootTime = kstat.BootTime
+	}
+
+	stats := make([]Proc, 0, len(procs))
+	for _, p := range procs {
+		proc, err := statProc(p, procPath, bootTime)
+		if err != nil {
+			// The process may have exited between AllProcs() and us reading
+			// its details; skip it rather than failing the whole collection.
+			continue
+		}
+		stats = append(stats, proc)
+	}
+
+	return stats, nil
+}
+
+// statProc reads everything we care about for a single process.
+func statProc(p procfs.Proc, procPath string, bootTime uint64) (Proc, error) {
+	stat, err := p.Stat()
+	if err != nil {
+		return Proc{}, err
+	}
+
+	cmdLine, err := p.CmdLine()
+	if err != nil {
+		cmdLine = []string{}
+	}
+
+	io, err := p.IO()
+	if err != nil {
+		// I/O accounting can be disabled or restricted by permissions; treat
+		// it as all-zero rather than dropping the process.
+		io = procfs.ProcIO{}
+	}
+
+	limits, err := p.Limits()
+	if err != nil {
+		limits = procfs.ProcLimits{}
+	}
+
+	fds, err := p.FileDescriptorsLen()
+	if err != nil {
+		fds = 0
+	}
+
+	proc := Proc{
+		Pid:        p.PID,
+		Cmd:        stat.Comm,
+		CmdLine:    strings.Join(cmdLine, "\x00"),
+		State:      stat.State,
+		VSize:      uint64(stat.VSize),
+		RSS:        uint64(stat.ResidentMemory()),
+		MinFlt:     uint64(stat.MinFlt),
+		MajFlt:     uint64(stat.MajFlt),
+		UTime:      uint64(stat.UTime),
+		STime:      uint64(stat.STime),
+		StackSize:  readStackSize(filepath.Join(procPath, strconv.Itoa(p.PID))),
+		StartTime:  uint64(stat.Starttime),
+		NumThreads: int64(stat.NumThreads),
+		NumFDs:     fds,
+		IO: ProcIO{
+			RChar:      io.RChar,
+			WChar:      io.WChar,
+			SyscR:      io.SyscR,
+			SyscW:      io.SyscW,
+			ReadBytes:  io.ReadBytes,
+			WriteBytes: io.WriteBytes,
+		},
+		Limits: limits,
+	}
+
+	if status, err := p.NewStatus(); err == nil {
+		proc.Data = status.VmData
+		proc.Code = status.VmExe
+		if len(status.UIDs) > 1 {
+			proc.UID = uint32(status.UIDs[1])
+		}
+	}
+
+	if bootTime > 0 {
+		proc.StartTimeUnix = int64(bootTime) + int64(proc.StartTime/clockTicksPerSecond)
+	}
+
+	dir := filepath.Join(procPath, strconv.Itoa(p.PID))
+	proc.CapEff, proc.SeccompMode, proc.GID = readStatusExtras(dir)
+	proc.Cgroup = readCgroup(dir)
+	proc.SELinuxLabel = readSELinuxLabel(dir)
+	proc.OomScore = readIntFile(filepath.Join(dir, "oom_score"))
+	proc.OomScoreAdj = readIntFile(filepath.Join(dir, "oom_score_adj"))
+
+	return proc, nil
+}
+
+// statStartStackField and statKstkespField are the zero-based indexes of
+// startstack (field 28) and kstkesp (field 29) in the /proc/<pid>/stat man
+// page, among the whitespace-separated fields that follow the process's
+// ")"-terminated comm, where state (field 3) is index 0. procfs.ProcStat
+// doesn't expose either one.
+const (
+	statStartStackField = 25
+	statKstkespField    = 26
+)
+
+// readStackSize approximates how much of the process's stack is actually in
+// use: startstack (the address of the bottom of the stack) minus kstkesp
+// (the current stack pointer), both parsed directly out of
+// /proc/<pid>/stat. A failure to read or parse the file is not fatal; it
+// just means this process reports a zero stack size.
+func readStackSize(dir string) uint64 {
+	data, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return 0
+	}
+
+	r := strings.LastIndex(string(data), ")")
+	if r < 0 || r+2 > len(data) {
+		return 0
+	}
+
+	fields := strings.Fields(string(data[r+2:]))
+	if len(fields) <= statKstkespField {
+		return 0
+	}
+
+	startStack, err := strconv.ParseUint(fields[statStartStackField], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	kstkesp, err := strconv.ParseUint(fields[statKstkespField], 10, 64)
+	if err != nil || kstkesp > startStack {
+		return 0
+	}
+
+	return startStack - kstkesp
+}
+
+// readStatusExtras parses the fields of /proc/<pid>/status that
+// procfs.ProcStatus doesn't expose: the effective capability mask, the
+// seccomp mode, and the real group id.
+func readStatusExtras(dir string) (capEff string, seccompMode int, gid uint32) {
+	f, err := os.Open(filepath.Join(dir, "status"))
+	if err != nil {
+		return "", 0, 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "CapEff:":
+			capEff = fields[1]
+		case "Seccomp:":
+			if v, err := strconv.Atoi(fields[1]); err == nil {
+				seccompMode = v
+			}
+		case "Gid:":
+			if v, err := strconv.ParseUint(fields[1], 10, 32); err == nil {
+				gid = uint32(v)
+			}
+		}
+	}
+
+	return capEff, seccompMode, gid
+}
+
+// readCgroup returns the process's unified (cgroup v2) cgroup path, falling
+// back to the first hierarchy listed on a cgroup v1 system.
+func readCgroup(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup"))
+	if err != nil {
+		return ""
+	}
+
+	var firstV1Path string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::")
+		}
+		if firstV1Path == "" {
+			if parts := strings.SplitN(line, ":", 3); len(parts) == 3 {
+				firstV1Path = parts[2]
+			}
+		}
+	}
+
+	return firstV1Path
+}
+
+// readSELinuxLabel returns the contents of /proc/<pid>/attr/current, or the
+// empty string if SELinux isn't enabled or the file can't be read.
+func readSELinuxLabel(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "attr", "current"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(data), "\x00\n")
+}
+
+// readIntFile reads a single integer out of a /proc file such as
+// oom_score or oom_score_adj, returning 0 if it can't be read or parsed.
+func readIntFile(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	v, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return v
+}
@@ -0,0 +1,117 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, contents string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+}
+
+func TestReadCgroupV2(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "cgroup", "0::/system.slice/docker-abcd1234.scope\n")
+
+	if got, want := readCgroup(dir), "/system.slice/docker-abcd1234.scope"; got != want {
+		t.Errorf("readCgroup() = %q, want %q", got, want)
+	}
+}
+
+func TestReadCgroupV1(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "cgroup", "11:memory:/docker/abcd1234\n10:cpu,cpuacct:/docker/abcd1234\n")
+
+	if got, want := readCgroup(dir), "/docker/abcd1234"; got != want {
+		t.Errorf("readCgroup() = %q, want %q", got, want)
+	}
+}
+
+func TestReadCgroupMissingFile(t *testing.T) {
+	if got := readCgroup(t.TempDir()); got != "" {
+		t.Errorf("readCgroup() of a missing file = %q, want empty", got)
+	}
+}
+
+func TestReadStatusExtras(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "status", ""+
+		"Name:\tbash\n"+
+		"Uid:\t1000\t1000\t1000\t1000\n"+
+		"Gid:\t1000\t1000\t1000\t1000\n"+
+		"Seccomp:\t2\n"+
+		"CapEff:\t0000003fffffffff\n")
+
+	capEff, seccompMode, gid := readStatusExtras(dir)
+	if capEff != "0000003fffffffff" {
+		t.Errorf("capEff = %q, want %q", capEff, "0000003fffffffff")
+	}
+	if seccompMode != 2 {
+		t.Errorf("seccompMode = %d, want 2", seccompMode)
+	}
+	if gid != 1000 {
+		t.Errorf("gid = %d, want 1000", gid)
+	}
+}
+
+func TestReadStatusExtrasMissingFile(t *testing.T) {
+	capEff, seccompMode, gid := readStatusExtras(t.TempDir())
+	if capEff != "" || seccompMode != 0 || gid != 0 {
+		t.Errorf("readStatusExtras() of a missing file = (%q, %d, %d), want zero values", capEff, seccompMode, gid)
+	}
+}
+
+func TestReadIntFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "oom_score_adj", "-500\n")
+
+	if got := readIntFile(filepath.Join(dir, "oom_score_adj")); got != -500 {
+		t.Errorf("readIntFile() = %d, want -500", got)
+	}
+
+	if got := readIntFile(filepath.Join(dir, "missing")); got != 0 {
+		t.Errorf("readIntFile() of a missing file = %d, want 0", got)
+	}
+}
+
+func TestReadSELinuxLabel(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "attr"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "attr"), "current", "system_u:system_r:container_t:s0\x00")
+
+	got := readSELinuxLabel(dir)
+	if want := "system_u:system_r:container_t:s0"; got != want {
+		t.Errorf("readSELinuxLabel() = %q, want %q", got, want)
+	}
+}
+
+func TestReadSELinuxLabelMissingFile(t *testing.T) {
+	if got := readSELinuxLabel(t.TempDir()); got != "" {
+		t.Errorf("readSELinuxLabel() of a missing file = %q, want empty", got)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ContainerTarget identifies a single container to collect process metrics
+// from, as configured via the "containers" config item, e.g.
+// `[{"runtime":"docker","id":"abcd1234"}]`.
+type ContainerTarget struct {
+	Runtime string `json:"runtime"`
+	ID      string `json:"id"`
+}
+
+// parseContainerTargets decodes the JSON array accepted by the "containers"
+// config item. An empty string is not an error; it just means no containers
+// were configured.
+func parseContainerTargets(raw string) ([]ContainerTarget, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var targets []ContainerTarget
+	if err := json.Unmarshal([]byte(raw), &targets); err != nil {
+		return nil, fmt.Errorf("invalid containers config: %v", err)
+	}
+
+	for _, t := range targets {
+		switch t.Runtime {
+		case "docker", "crio", "containerd":
+		default:
+			return nil, fmt.Errorf("unsupported container runtime %q", t.Runtime)
+		}
+	}
+
+	return targets, nil
+}
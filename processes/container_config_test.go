@@ -0,0 +1,66 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import "testing"
+
+func TestParseContainerTargetsEmpty(t *testing.T) {
+	targets, err := parseContainerTargets("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if targets != nil {
+		t.Fatalf("expected nil targets for empty config, got %v", targets)
+	}
+}
+
+func TestParseContainerTargetsValid(t *testing.T) {
+	raw := `[{"runtime":"docker","id":"abcd1234"},{"runtime":"crio","id":"deadbeef"}]`
+	targets, err := parseContainerTargets(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []ContainerTarget{
+		{Runtime: "docker", ID: "abcd1234"},
+		{Runtime: "crio", ID: "deadbeef"},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("got %d targets, want %d", len(targets), len(want))
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Errorf("target %d: got %+v, want %+v", i, targets[i], want[i])
+		}
+	}
+}
+
+func TestParseContainerTargetsUnsupportedRuntime(t *testing.T) {
+	_, err := parseContainerTargets(`[{"runtime":"rkt","id":"abcd1234"}]`)
+	if err == nil {
+		t.Fatal("expected an error for an unsupported runtime, got nil")
+	}
+}
+
+func TestParseContainerTargetsInvalidJSON(t *testing.T) {
+	_, err := parseContainerTargets(`not json`)
+	if err == nil {
+		t.Fatal("expected an error for invalid JSON, got nil")
+	}
+}
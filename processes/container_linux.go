@@ -0,0 +1,151 @@
+//go:build linux
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// initPID resolves the PID of the container's init process so that we can
+// join its namespaces. Each runtime is queried through its own CLI rather
+// than its socket API, keeping this plugin free of extra client
+// dependencies.
+func (t ContainerTarget) initPID() (int, error) {
+	var cmd *exec.Cmd
+	switch t.Runtime {
+	case "docker":
+		cmd = exec.Command("docker", "inspect", "-f", "{{.State.Pid}}", t.ID)
+	case "containerd":
+		cmd = exec.Command("ctr", "-n", "k8s.io", "task", "ps", "-p", t.ID)
+	case "crio":
+		cmd = exec.Command("crictl", "inspect", "-o", "go-template", "--template", "{{.info.pid}}", t.ID)
+	default:
+		return 0, fmt.Errorf("unsupported container runtime %q", t.Runtime)
+	}
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("resolving init pid for %s container %s: %v", t.Runtime, t.ID, err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(out.String()))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected pid output for %s container %s: %q", t.Runtime, t.ID, out.String())
+	}
+	return pid, nil
+}
+
+// CollectInContainer joins the mount namespace of the container's init
+// process on a dedicated, locked OS thread and runs mc.GetStats from inside
+// it, so the returned Proc entries reflect that container's /proc view
+// rather than the host's.
+//
+// Only the mount namespace is joined. setns(2) on a PID namespace changes
+// which namespace *children created afterwards* land in; it has no effect
+// on the calling thread's own view of /proc, and we never fork+exec here.
+// The container's /proc mount is already bound to its own PID namespace at
+// mount time, so joining its mount namespace is what actually changes which
+// processes GetStats sees.
+func CollectInContainer(mc metricCollector, procPath string, target ContainerTarget) ([]Proc, error) {
+	pid, err := target.initPID()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		stats []Proc
+		done  = make(chan error, 1)
+	)
+
+	go func() {
+		runtime.LockOSThread()
+
+		selfNs, err := openMountNamespace(0)
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- err
+			return
+		}
+		defer selfNs.Close()
+
+		targetNs, err := openMountNamespace(pid)
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- err
+			return
+		}
+
+		err = unix.Setns(int(targetNs.Fd()), unix.CLONE_NEWNS)
+		targetNs.Close()
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- fmt.Errorf("setns mnt namespace of pid %d: %v", pid, err)
+			return
+		}
+
+		var collectErr error
+		stats, collectErr = mc.GetStats(procPath)
+
+		if restoreErr := unix.Setns(int(selfNs.Fd()), unix.CLONE_NEWNS); restoreErr != nil {
+			// The thread is now stuck inside the target's mount namespace.
+			// Returning it to the runtime's thread pool via
+			// UnlockOSThread would let some unrelated goroutine resume on
+			// a thread with the wrong mount namespace, so kill the thread
+			// instead of unlocking it.
+			done <- fmt.Errorf("restoring mnt namespace after collecting pid %d: %v", pid, restoreErr)
+			runtime.Goexit()
+		}
+
+		runtime.UnlockOSThread()
+		done <- collectErr
+	}()
+
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// openMountNamespace opens the mnt namespace file of pid, or of the calling
+// thread itself when pid is 0.
+func openMountNamespace(pid int) (*os.File, error) {
+	path := "/proc/self/ns/mnt"
+	if pid != 0 {
+		path = fmt.Sprintf("/proc/%d/ns/mnt", pid)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %v", path, err)
+	}
+	return f, nil
+}
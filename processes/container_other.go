@@ -0,0 +1,32 @@
+//go:build !linux
+// +build !linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import "fmt"
+
+// CollectInContainer is unsupported outside of linux: joining another
+// process's namespaces relies on setns(2), which has no equivalent on other
+// platforms.
+func CollectInContainer(mc metricCollector, procPath string, target ContainerTarget) ([]Proc, error) {
+	return nil, fmt.Errorf("container-aware collection is not supported on this platform")
+}
@@ -0,0 +1,154 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Exporter adapts the same metricCollector used by CollectMetrics to the
+// prometheus.Collector interface, so this plugin can be scraped directly by
+// Prometheus alongside (or instead of) running under Snap.
+type Exporter struct {
+	mc       metricCollector
+	procPath string
+	rates    *rateTracker
+
+	// mu guards the reset-populate-collect sequence in Collect: the
+	// prometheus.Collector contract requires Collect to tolerate
+	// concurrent/overlapping scrapes, but gauges/stateGauge are mutated
+	// in place via Reset() and repopulated, which isn't safe to interleave.
+	mu         sync.Mutex
+	gauges     map[string]*prometheus.GaugeVec
+	stateGauge *prometheus.GaugeVec
+}
+
+// NewExporter returns an Exporter that collects process stats from procPath
+// using mc.
+func NewExporter(mc metricCollector, procPath string) *Exporter {
+	gauges := make(map[string]*prometheus.GaugeVec, len(metricNames))
+	for name, lbl := range metricNames {
+		gauges[name] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: name,
+			Help: lbl.description,
+		}, []string{"pid", "comm", "state"})
+	}
+
+	return &Exporter{
+		mc:       mc,
+		procPath: procPath,
+		rates:    newRateTracker(),
+		gauges:   gauges,
+		stateGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "processes_state_count",
+			Help: "Number of processes in a given state",
+		}, []string{"state"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, g := range e.gauges {
+		g.Describe(ch)
+	}
+	e.stateGauge.Describe(ch)
+}
+
+// Collect implements prometheus.Collector. It runs the same mc.GetStats used
+// by CollectMetrics so the Snap and Prometheus code paths never drift apart.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, g := range e.gauges {
+		g.Reset()
+	}
+	e.stateGauge.Reset()
+
+	stats, err := e.mc.GetStats(e.procPath)
+	if err != nil {
+		return
+	}
+
+	stateCount := map[string]int{}
+	for _, state := range States.Values() {
+		stateCount[state] = 0
+	}
+
+	now := time.Now()
+	live := make(map[rateKey]bool, len(stats))
+
+	for _, proc := range stats {
+		stateName := States[proc.State]
+		stateCount[stateName]++
+
+		metrics := setProcMetrics(proc)
+		if rm, ok := e.rates.update("host", proc, now); ok {
+			for name, val := range setRateMetrics(rm) {
+				metrics[name] = val
+			}
+		}
+		live[rateKey{containerID: "host", pid: proc.Pid, startTime: proc.StartTime}] = true
+
+		pid := strconv.Itoa(proc.Pid)
+		for name, val := range metrics {
+			f, ok := toFloat64(val)
+			if !ok {
+				continue
+			}
+			e.gauges[name].WithLabelValues(pid, proc.Cmd, stateName).Set(f)
+		}
+	}
+	e.rates.evict(live)
+
+	for state, count := range stateCount {
+		e.stateGauge.WithLabelValues(state).Set(float64(count))
+	}
+
+	for _, g := range e.gauges {
+		g.Collect(ch)
+	}
+	e.stateGauge.Collect(ch)
+}
+
+// toFloat64 converts the interface{} values produced by setProcMetrics and
+// setRateMetrics into the float64 required by Prometheus gauges.
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case uint64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case string:
+		return 0, false
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,185 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ProcessFilter narrows the all-dynamic namespace branch down to a subset of
+// processes, as configured via the top-level "process_filter" config item,
+// e.g. `{"cmdline_regex":"^/usr/bin/foo","min_rss":1048576,"uids":[0,1000]}`.
+type ProcessFilter struct {
+	CmdlineRegex string   `json:"cmdline_regex"`
+	MinRSS       uint64   `json:"min_rss"`
+	UIDs         []uint32 `json:"uids"`
+
+	cmdlineRe *regexp.Regexp
+}
+
+// parseProcessFilter decodes the JSON object accepted by the
+// "process_filter" config item, compiling its regex once so it isn't
+// recompiled for every process on every collect. An empty string is not an
+// error; it just means no filter is configured.
+func parseProcessFilter(raw string) (*ProcessFilter, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var f ProcessFilter
+	if err := json.Unmarshal([]byte(raw), &f); err != nil {
+		return nil, fmt.Errorf("invalid process_filter config: %v", err)
+	}
+
+	if f.CmdlineRegex != "" {
+		re, err := regexp.Compile(f.CmdlineRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid process_filter cmdline_regex: %v", err)
+		}
+		f.cmdlineRe = re
+	}
+
+	return &f, nil
+}
+
+// matches reports whether proc passes every configured criterion. A nil
+// filter matches everything.
+func (f *ProcessFilter) matches(proc Proc) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.cmdlineRe != nil && !f.cmdlineRe.MatchString(proc.CmdLine) {
+		return false
+	}
+
+	if f.MinRSS > 0 && proc.RSS < f.MinRSS {
+		return false
+	}
+
+	if len(f.UIDs) > 0 {
+		found := false
+		for _, uid := range f.UIDs {
+			if proc.UID == uid {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nameFilterCache memoizes the compiled regular expressions behind
+// "/regex/" process-name filters. A single CollectMetrics call can ask
+// matchesNameFilter the same filter string once per requested metric, so
+// without this cache a "/regex/" filter gets recompiled len(metricTypes)
+// times per process instead of once.
+type nameFilterCache map[string]*regexp.Regexp
+
+// compile returns the compiled regex behind filter, compiling and caching
+// it on first use. It returns nil (and caches nil) if filter isn't a
+// "/regex/"-wrapped pattern, or if that pattern fails to compile.
+func (c nameFilterCache) compile(filter string) *regexp.Regexp {
+	re, ok := c[filter]
+	if !ok {
+		if len(filter) > 1 && strings.HasPrefix(filter, "/") && strings.HasSuffix(filter, "/") {
+			re, _ = regexp.Compile(filter[1 : len(filter)-1])
+		}
+		c[filter] = re
+	}
+	return re
+}
+
+// matchesNameFilter reports whether cmd (the process's bare command name)
+// satisfies filter, which may be an exact name, a shell glob
+// (filepath.Match syntax), or a "/regex/" wrapped regular expression. re
+// is the result of cache.compile(filter); the caller is expected to
+// compile it once per collect rather than once per process.
+func matchesNameFilter(cmd, filter string, re *regexp.Regexp) bool {
+	if filter == "" || filter == "*" {
+		return true
+	}
+
+	if len(filter) > 1 && strings.HasPrefix(filter, "/") && strings.HasSuffix(filter, "/") {
+		if re == nil {
+			return false
+		}
+		return re.MatchString(cmd)
+	}
+
+	if matched, err := filepath.Match(filter, cmd); err == nil && matched {
+		return true
+	}
+
+	return cmd == filter
+}
+
+// matchesPidFilter reports whether pid is covered by filter, a comma
+// separated list of exact pids and/or "<low>-<high>" ranges, e.g.
+// "1000-2000,4242".
+func matchesPidFilter(pid int, filter string) bool {
+	if filter == "" || filter == "*" {
+		return true
+	}
+
+	for _, part := range strings.Split(filter, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if lo, hi, ok := parsePidRange(part); ok {
+			if pid >= lo && pid <= hi {
+				return true
+			}
+			continue
+		}
+
+		if n, err := strconv.Atoi(part); err == nil && n == pid {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parsePidRange(part string) (lo, hi int, ok bool) {
+	bounds := strings.SplitN(part, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+
+	lo, errLo := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	hi, errHi := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if errLo != nil || errHi != nil {
+		return 0, 0, false
+	}
+
+	return lo, hi, true
+}
@@ -0,0 +1,189 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import "testing"
+
+func TestParseProcessFilterEmpty(t *testing.T) {
+	f, err := parseProcessFilter("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f != nil {
+		t.Fatalf("expected a nil filter for empty config, got %+v", f)
+	}
+}
+
+func TestParseProcessFilterInvalidJSON(t *testing.T) {
+	if _, err := parseProcessFilter("not json"); err == nil {
+		t.Fatal("expected an error for invalid JSON, got nil")
+	}
+}
+
+func TestParseProcessFilterInvalidRegex(t *testing.T) {
+	if _, err := parseProcessFilter(`{"cmdline_regex":"("}`); err == nil {
+		t.Fatal("expected an error for an invalid regex, got nil")
+	}
+}
+
+func TestProcessFilterMatchesNilFilter(t *testing.T) {
+	var f *ProcessFilter
+	if !f.matches(Proc{Pid: 1}) {
+		t.Error("a nil filter should match everything")
+	}
+}
+
+func TestProcessFilterMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		proc Proc
+		want bool
+	}{
+		{
+			name: "cmdline regex matches",
+			raw:  `{"cmdline_regex":"^/usr/bin/foo"}`,
+			proc: Proc{CmdLine: "/usr/bin/foo\x00-v"},
+			want: true,
+		},
+		{
+			name: "cmdline regex does not match",
+			raw:  `{"cmdline_regex":"^/usr/bin/foo"}`,
+			proc: Proc{CmdLine: "/usr/bin/bar"},
+			want: false,
+		},
+		{
+			name: "min rss satisfied",
+			raw:  `{"min_rss":1024}`,
+			proc: Proc{RSS: 2048},
+			want: true,
+		},
+		{
+			name: "min rss not satisfied",
+			raw:  `{"min_rss":1024}`,
+			proc: Proc{RSS: 512},
+			want: false,
+		},
+		{
+			name: "uid allow-listed",
+			raw:  `{"uids":[0,1000]}`,
+			proc: Proc{UID: 1000},
+			want: true,
+		},
+		{
+			name: "uid not allow-listed",
+			raw:  `{"uids":[0,1000]}`,
+			proc: Proc{UID: 1001},
+			want: false,
+		},
+		{
+			name: "all criteria must match",
+			raw:  `{"min_rss":1024,"uids":[1000]}`,
+			proc: Proc{RSS: 2048, UID: 0},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseProcessFilter(tt.raw)
+			if err != nil {
+				t.Fatalf("parseProcessFilter(%q): %v", tt.raw, err)
+			}
+			if got := f.matches(tt.proc); got != tt.want {
+				t.Errorf("matches(%+v) = %v, want %v", tt.proc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesNameFilter(t *testing.T) {
+	tests := []struct {
+		cmd, filter string
+		want        bool
+	}{
+		{"nginx", "", true},
+		{"nginx", "*", true},
+		{"nginx", "nginx", true},
+		{"nginx", "apache", false},
+		{"nginx", "ngin*", true},
+		{"nginx", "/^ngi/", true},
+		{"nginx", "/^apa/", false},
+		{"nginx", "/[/", false},
+	}
+
+	cache := nameFilterCache{}
+	for _, tt := range tests {
+		re := cache.compile(tt.filter)
+		if got := matchesNameFilter(tt.cmd, tt.filter, re); got != tt.want {
+			t.Errorf("matchesNameFilter(%q, %q) = %v, want %v", tt.cmd, tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestNameFilterCacheCompilesOnce(t *testing.T) {
+	cache := nameFilterCache{}
+	first := cache.compile("/^ngi/")
+	second := cache.compile("/^ngi/")
+	if first == nil || second == nil {
+		t.Fatal("expected a compiled regex for both calls")
+	}
+	if first != second {
+		t.Error("compile() should return the cached *regexp.Regexp on the second call, not recompile")
+	}
+}
+
+func TestMatchesPidFilter(t *testing.T) {
+	tests := []struct {
+		pid    int
+		filter string
+		want   bool
+	}{
+		{42, "", true},
+		{42, "*", true},
+		{42, "42", true},
+		{42, "41", false},
+		{42, "1000-2000,42", true},
+		{42, "1000-2000", false},
+		{1500, "1000-2000", true},
+		{1500, "1000-2000,4242", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchesPidFilter(tt.pid, tt.filter); got != tt.want {
+			t.Errorf("matchesPidFilter(%d, %q) = %v, want %v", tt.pid, tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestParsePidRange(t *testing.T) {
+	lo, hi, ok := parsePidRange("100-200")
+	if !ok || lo != 100 || hi != 200 {
+		t.Errorf("parsePidRange(%q) = (%d, %d, %v), want (100, 200, true)", "100-200", lo, hi, ok)
+	}
+
+	if _, _, ok := parsePidRange("100"); ok {
+		t.Error("parsePidRange of a single pid should not be treated as a range")
+	}
+
+	if _, _, ok := parsePidRange("abc-def"); ok {
+		t.Error("parsePidRange of non-numeric bounds should fail")
+	}
+}
@@ -21,9 +21,12 @@ package processes
 
 import (
 	"fmt"
+	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/intelsdi-x/snap/control/plugin"
@@ -33,6 +36,9 @@ import (
 
 	"github.com/intelsdi-x/snap-plugin-utilities/config"
 	"github.com/intelsdi-x/snap-plugin-utilities/str"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -108,6 +114,70 @@ var (
 			description: "Process command line with full path",
 			unit:        "",
 		},
+		"ps_cpu_user_pct": label{
+			description: "Percentage of total CPU time (all cores together) spent by this process in user mode since the previous collection",
+			unit:        "%",
+		},
+		"ps_cpu_system_pct": label{
+			description: "Percentage of total CPU time (all cores together) spent by this process in kernel mode since the previous collection",
+			unit:        "%",
+		},
+		"ps_cpu_total_pct": label{
+			description: "Percentage of total CPU time (all cores together) spent by this process (user + system) since the previous collection",
+			unit:        "%",
+		},
+		"ps_disk_octets_rchar_rate": label{
+			description: "Bytes read from storage by this process per second since the previous collection",
+			unit:        "B/s",
+		},
+		"ps_disk_octets_wchar_rate": label{
+			description: "Bytes written to storage by this process per second since the previous collection",
+			unit:        "B/s",
+		},
+		"ps_capabilities_eff": label{
+			description: "Effective capability mask, as a hex string (CapEff in /proc/<pid>/status)",
+			unit:        "",
+		},
+		"ps_seccomp_mode": label{
+			description: "Seccomp mode: 0 disabled, 1 strict, 2 filter",
+			unit:        "",
+		},
+		"ps_oom_score": label{
+			description: "Current OOM killer score",
+			unit:        "",
+		},
+		"ps_oom_score_adj": label{
+			description: "Adjustment applied to the OOM killer score",
+			unit:        "",
+		},
+		"ps_cgroup": label{
+			description: "Unified (or first reported) cgroup path the process belongs to",
+			unit:        "",
+		},
+		"ps_selinux_label": label{
+			description: "SELinux security context, if SELinux is enabled",
+			unit:        "",
+		},
+		"ps_uid": label{
+			description: "Effective user id",
+			unit:        "",
+		},
+		"ps_gid": label{
+			description: "Real group id",
+			unit:        "",
+		},
+		"ps_num_threads": label{
+			description: "Number of threads currently in the process",
+			unit:        "",
+		},
+		"ps_num_fds": label{
+			description: "Number of open file descriptors",
+			unit:        "",
+		},
+		"ps_start_time_unix": label{
+			description: "Process start time as a unix timestamp",
+			unit:        "s",
+		},
 	}
 )
 
@@ -118,7 +188,7 @@ func New() *procPlugin {
 		host = "localhost"
 	}
 
-	return &procPlugin{host: host, mc: &procStatsCollector{}}
+	return &procPlugin{host: host, mc: &procStatsCollector{}, rates: newRateTracker()}
 }
 
 // Meta returns plugin meta data
@@ -139,7 +209,9 @@ func (procPlg *procPlugin) GetMetricTypes(cfg plugin.ConfigType) ([]plugin.Metri
 	// build metric types from process metric names
 	for metricName, label := range metricNames {
 		metricType := plugin.MetricType{
-			Namespace_: core.NewNamespace(pluginVendor, fs, pluginName, "pid").
+			Namespace_: core.NewNamespace(pluginVendor, fs, pluginName).
+				AddDynamicElement("container_id", "id of the container the process belongs to, or \"host\" when collected from the host namespace").
+				AddStaticElements("pid").
 				AddDynamicElement("process_id", "pid of the running process").
 				AddDynamicElement("process_name", "name of the running process").
 				AddStaticElements(metricName),
@@ -168,10 +240,38 @@ func (procPlg *procPlugin) GetConfigPolicy() (*cpolicy.ConfigPolicy, error) {
 	rule, _ := cpolicy.NewStringRule("proc_path", false, "/proc")
 	node := cpolicy.NewPolicyNode()
 	node.Add(rule)
+	listenAddrRule, _ := cpolicy.NewStringRule("listen_addr", false, "")
+	node.Add(listenAddrRule)
+	containersRule, _ := cpolicy.NewStringRule("containers", false, "")
+	node.Add(containersRule)
+	processFilterRule, _ := cpolicy.NewStringRule("process_filter", false, "")
+	node.Add(processFilterRule)
 	cp.Add([]string{pluginVendor, fs, pluginName}, node)
 	return cp, nil
 }
 
+// startExporter launches the Prometheus exporter HTTP endpoint the first
+// time a non-empty listen_addr is seen, and is a no-op on subsequent calls.
+func (procPlg *procPlugin) startExporter(listenAddr, procPath string) {
+	if listenAddr == "" {
+		return
+	}
+	procPlg.startExporterOnce.Do(func() {
+		exporter := NewExporter(procPlg.mc, procPath)
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(exporter)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+		go func() {
+			if err := http.ListenAndServe(listenAddr, mux); err != nil {
+				log.Printf("processes: exporter http server stopped: %v", err)
+			}
+		}()
+	})
+}
+
 // CollectMetrics retrieves values for given metrics types
 func (procPlg *procPlugin) CollectMetrics(metricTypes []plugin.MetricType) ([]plugin.MetricType, error) {
 	metrics := []plugin.MetricType{}
@@ -182,23 +282,80 @@ func (procPlg *procPlugin) CollectMetrics(metricTypes []plugin.MetricType) ([]pl
 		return nil, err
 	}
 
+	if listenAddr, err := config.GetConfigItem(metricTypes[0], "listen_addr"); err == nil {
+		procPlg.startExporter(listenAddr.(string), procPath.(string))
+	}
+
 	// init stateCount map with keys from States
 	for _, state := range States.Values() {
 		stateCount[state] = 0
 	}
 
-	// get all proc stats
+	// get all proc stats from the host namespace
 	stats, err := procPlg.mc.GetStats(procPath.(string))
 	if err != nil {
 		return nil, serror.New(err)
 	}
 
-	// calculate number of processes in each state
+	scopes := []containerScope{{containerID: "host", stats: stats}}
+
+	if containersCfg, cerr := config.GetConfigItem(metricTypes[0], "containers"); cerr == nil {
+		targets, perr := parseContainerTargets(containersCfg.(string))
+		if perr != nil {
+			return nil, serror.New(perr)
+		}
+		for _, target := range targets {
+			cstats, cerr := CollectInContainer(procPlg.mc, procPath.(string), target)
+			if cerr != nil {
+				// A single unreachable container shouldn't fail collection
+				// for the host and every other configured container.
+				continue
+			}
+			scopes = append(scopes, containerScope{containerID: target.ID, stats: cstats})
+		}
+	}
+
+	// calculate number of processes in each state (host namespace only)
 	for _, proc := range stats {
 		stateName := States[proc.State]
 		stateCount[stateName]++
 	}
 
+	var processFilter *ProcessFilter
+	if filterCfg, ferr := config.GetConfigItem(metricTypes[0], "process_filter"); ferr == nil {
+		processFilter, err = parseProcessFilter(filterCfg.(string))
+		if err != nil {
+			return nil, serror.New(err)
+		}
+	}
+
+	// Derive each proc's metrics (including the rateTracker-derived CPU%/IO
+	// rate ones) exactly once per collect, no matter how many metricTypes
+	// were requested, and remember which (container, pid, starttime) keys
+	// are still alive so rateTracker can forget the rest.
+	now := time.Now()
+	live := map[rateKey]bool{}
+	for i := range scopes {
+		scope := &scopes[i]
+		scope.metrics = make([]map[string]interface{}, len(scope.stats))
+		for j, proc := range scope.stats {
+			procMetrics := setProcMetrics(proc)
+			if rm, ok := procPlg.rates.update(scope.containerID, proc, now); ok {
+				for name, val := range setRateMetrics(rm) {
+					procMetrics[name] = val
+				}
+			}
+			scope.metrics[j] = procMetrics
+			live[rateKey{containerID: scope.containerID, pid: proc.Pid, startTime: proc.StartTime}] = true
+		}
+	}
+	procPlg.rates.evict(live)
+
+	// nameFilters memoizes compiled "/regex/" name filters across the whole
+	// metricType loop below, so a given filter string is compiled once per
+	// collect rather than once per (metricType, process) pair.
+	nameFilters := nameFilterCache{}
+
 	// calculate metrics
 	for _, metricType := range metricTypes {
 		ns := metricType.Namespace()
@@ -208,35 +365,123 @@ func (procPlg *procPlugin) CollectMetrics(metricTypes []plugin.MetricType) ([]pl
 
 		isDynamic, _ := ns.IsDynamic()
 		if isDynamic {
+			for _, scope := range scopes {
+				// ns[3] pins metrics to a specific container; a dynamic ns[3]
+				// keeps every scope, while a static value (including "host")
+				// keeps only the scope with that container_id
+				if !ns[3].IsDynamic() && ns[3].Value != scope.containerID {
+					continue
+				}
 
-			//pid and name is dynamic = all
-			if ns[4].IsDynamic() && ns[5].IsDynamic() {
-				for _, proc := range stats {
-					procMetrics := setProcMetrics(proc)
-					for procMet, val := range procMetrics {
-						if procMet == ns[6].Value {
-							// change dynamic namespace element value (= "*") to current process name
-							// whole namespace stays dynamic (ns[3].Name != "")
-							nuns := core.Namespace(append([]core.NamespaceElement{}, ns...))
-							nuns[4].Value = strconv.Itoa(proc.Pid)
-							cmdPath := strings.Split(strings.Split(proc.CmdLine, "\x00")[0], "/")
-							nuns[5].Value = cmdPath[len(cmdPath)-1]
+				//pid and name is dynamic = all
+				if ns[5].IsDynamic() && ns[6].IsDynamic() {
+					for idx, proc := range scope.stats {
+						if !processFilter.matches(proc) {
+							continue
+						}
+						procMetrics := scope.metrics[idx]
+						for procMet, val := range procMetrics {
+							if procMet == ns[7].Value {
+								// change dynamic namespace element values to
+								// the current container/process identity;
+								// the namespace stays dynamic (ns[i].Name != "")
+								nuns := core.Namespace(append([]core.NamespaceElement{}, ns...))
+								nuns[3].Value = scope.containerID
+								nuns[5].Value = strconv.Itoa(proc.Pid)
+								cmdPath := strings.Split(strings.Split(proc.CmdLine, "\x00")[0], "/")
+								nuns[6].Value = cmdPath[len(cmdPath)-1]
+								metric := plugin.MetricType{
+									Namespace_:   nuns,
+									Data_:        val,
+									Timestamp_:   time.Now(),
+									Unit_:        metricNames[procMet].unit,
+									Description_: metricNames[procMet].description,
+								}
+								metrics = append(metrics, metric)
+							}
+						}
+					}
+					// only pid dynamic: ns[6].Value pins the process name to
+					// match, either exactly, as a shell glob, or as a
+					// "/regex/"
+				} else if ns[5].IsDynamic() {
+					nameFilter := ns[6].Value
+					nameRe := nameFilters.compile(nameFilter)
+					for idx, proc := range scope.stats {
+						cmdPath := strings.Split(strings.Split(proc.CmdLine, "\x00")[0], "/")
+						cmdName := cmdPath[len(cmdPath)-1]
+						if !matchesNameFilter(cmdName, nameFilter, nameRe) {
+							continue
+						}
+						procMetrics := scope.metrics[idx]
+						for procMet, val := range procMetrics {
+							if procMet == ns[7].Value {
+								nuns := core.Namespace(append([]core.NamespaceElement{}, ns...))
+								nuns[3].Value = scope.containerID
+								nuns[5].Value = strconv.Itoa(proc.Pid)
+								metric := plugin.MetricType{
+									Namespace_:   nuns,
+									Data_:        val,
+									Timestamp_:   time.Now(),
+									Unit_:        metricNames[procMet].unit,
+									Description_: metricNames[procMet].description,
+								}
+								metrics = append(metrics, metric)
+							}
+						}
+					}
+					// only name dynamic: ns[5].Value pins the pid(s) to
+					// match, as an exact pid, a "lo-hi" range, or a
+					// comma-separated list of either
+				} else if ns[6].IsDynamic() {
+					pidFilter := ns[5].Value
+					for idx, proc := range scope.stats {
+						if !matchesPidFilter(proc.Pid, pidFilter) {
+							continue
+						}
+						procMetrics := scope.metrics[idx]
+						for procMet, val := range procMetrics {
+							if procMet == ns[7].Value {
+								nuns := core.Namespace(append([]core.NamespaceElement{}, ns...))
+								nuns[3].Value = scope.containerID
+								cmdPath := strings.Split(strings.Split(proc.CmdLine, "\x00")[0], "/")
+								nuns[6].Value = cmdPath[len(cmdPath)-1]
+								metric := plugin.MetricType{
+									Namespace_:   nuns,
+									Data_:        val,
+									Timestamp_:   time.Now(),
+									Unit_:        metricNames[procMet].unit,
+									Description_: metricNames[procMet].description,
+								}
+								metrics = append(metrics, metric)
+							}
+						}
+					}
+					// neither pid nor name dynamic: only container_id is,
+					// a single fully-qualified process in a dynamic scope
+				} else {
+					for idx, proc := range scope.stats {
+						if strconv.Itoa(proc.Pid) != ns[5].Value {
+							continue
+						}
+						cmdPath := strings.Split(strings.Split(proc.CmdLine, "\x00")[0], "/")
+						if cmdPath[len(cmdPath)-1] != ns[6].Value {
+							continue
+						}
+						procMetrics := scope.metrics[idx]
+						if val, ok := procMetrics[ns[7].Value]; ok {
 							metric := plugin.MetricType{
-								Namespace_:   nuns,
+								Namespace_:   ns,
 								Data_:        val,
 								Timestamp_:   time.Now(),
-								Unit_:        metricNames[procMet].unit,
-								Description_: metricNames[procMet].description,
+								Unit_:        metricNames[ns[7].Value].unit,
+								Description_: metricNames[ns[7].Value].description,
 							}
 							metrics = append(metrics, metric)
 						}
+						break
 					}
 				}
-				// only pid dynamic
-			} else if ns[4].IsDynamic() {
-				// only name dynamic
-			} else {
-
 			}
 		} else if str.Contains(States.Values(), ns[3].Value) {
 			// ns[3] contains process state
@@ -257,6 +502,15 @@ func (procPlg *procPlugin) CollectMetrics(metricTypes []plugin.MetricType) ([]pl
 	return metrics, nil
 }
 
+// containerScope pairs a set of collected Procs with the container_id they
+// should be tagged with ("host" for the top-level /proc namespace), plus
+// the metrics derived for each of those Procs for the current collect.
+type containerScope struct {
+	containerID string
+	stats       []Proc
+	metrics     []map[string]interface{}
+}
+
 func setProcMetrics(proc Proc) map[string]interface{} {
 
 	procMetrics := map[string]interface{}{}
@@ -264,44 +518,33 @@ func setProcMetrics(proc Proc) map[string]interface{} {
 	for metricName, _ := range metricNames {
 		procMetrics[metricName] = 0
 	}
-	vm, _ := strconv.ParseUint(string(proc.Stat[22]), 10, 64)
-	procMetrics["ps_vm"] = vm
-
-	rss, _ := strconv.ParseUint(string(proc.Stat[23]), 10, 64)
-	procMetrics["ps_rss"] = rss
-
-	procMetrics["ps_data"] = proc.VmData
-	procMetrics["ps_code"] = proc.VmCode
 
-	stack1, _ := strconv.ParseUint(string(proc.Stat[27]), 10, 64)
-	stack2, _ := strconv.ParseUint(string(proc.Stat[28]), 10, 64)
-
-	// to avoid overload
-	if stack1 > stack2 {
-		procMetrics["ps_stacksize"] = stack1 - stack2
-	} else {
-		procMetrics["ps_stacksize"] = stack2 - stack1
-	}
-
-	utime, _ := strconv.ParseUint(string(proc.Stat[13]), 10, 64)
-	procMetrics["ps_cputime_user"] = utime
-
-	stime, _ := strconv.ParseUint(string(proc.Stat[14]), 10, 64)
-	procMetrics["ps_cputime_system"] = stime
-
-	minflt, _ := strconv.ParseUint(string(proc.Stat[9]), 10, 64)
-	procMetrics["ps_pagefaults_min"] = minflt
-
-	majflt, _ := strconv.ParseUint(string(proc.Stat[11]), 10, 64)
-	procMetrics["ps_pagefaults_maj"] = majflt
-
-	procMetrics["ps_disk_octets_rchar"] = proc.Io["rchar"]
-	procMetrics["ps_disk_octets_wchar"] = proc.Io["wchar"]
-	procMetrics["ps_disk_ops_syscr"] = proc.Io["syscr"]
-	procMetrics["ps_disk_ops_syscw"] = proc.Io["syscw"]
+	procMetrics["ps_vm"] = proc.VSize
+	procMetrics["ps_rss"] = proc.RSS
+	procMetrics["ps_data"] = proc.Data
+	procMetrics["ps_code"] = proc.Code
+	procMetrics["ps_stacksize"] = proc.StackSize
+	procMetrics["ps_cputime_user"] = proc.UTime
+	procMetrics["ps_cputime_system"] = proc.STime
+	procMetrics["ps_pagefaults_min"] = proc.MinFlt
+	procMetrics["ps_pagefaults_maj"] = proc.MajFlt
+	procMetrics["ps_disk_octets_rchar"] = proc.IO.RChar
+	procMetrics["ps_disk_octets_wchar"] = proc.IO.WChar
+	procMetrics["ps_disk_ops_syscr"] = proc.IO.SyscR
+	procMetrics["ps_disk_ops_syscw"] = proc.IO.SyscW
 	procMetrics["ps_cmd_line"] = proc.CmdLine
 	procMetrics["ps_cmd"] = proc.Cmd
-	//	}
+	procMetrics["ps_capabilities_eff"] = proc.CapEff
+	procMetrics["ps_seccomp_mode"] = proc.SeccompMode
+	procMetrics["ps_oom_score"] = proc.OomScore
+	procMetrics["ps_oom_score_adj"] = proc.OomScoreAdj
+	procMetrics["ps_cgroup"] = proc.Cgroup
+	procMetrics["ps_selinux_label"] = proc.SELinuxLabel
+	procMetrics["ps_uid"] = proc.UID
+	procMetrics["ps_gid"] = proc.GID
+	procMetrics["ps_num_threads"] = proc.NumThreads
+	procMetrics["ps_num_fds"] = proc.NumFDs
+	procMetrics["ps_start_time_unix"] = proc.StartTimeUnix
 
 	return procMetrics
 }
@@ -314,6 +557,14 @@ func fillNsElement(element *core.NamespaceElement, value string) core.NamespaceE
 type procPlugin struct {
 	host string
 	mc   metricCollector
+
+	// startExporterOnce guards the lazily-started Prometheus exporter HTTP
+	// server so it is only started once, the first time listen_addr is set.
+	startExporterOnce sync.Once
+
+	// rates derives ps_cpu_*_pct and ps_disk_octets_*_rate from the deltas
+	// between successive collects.
+	rates *rateTracker
 }
 
 type label struct {
@@ -0,0 +1,170 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// fakeProcessCollector is a metricCollector stub standing in for the real
+// /proc filesystem.
+type fakeProcessCollector struct {
+	stats []Proc
+}
+
+func (f *fakeProcessCollector) GetStats(procPath string) ([]Proc, error) {
+	return f.stats, nil
+}
+
+// newTestMetricType builds a plugin.MetricType whose namespace matches the
+// ones GetMetricTypes produces: intel.procfs.processes.<container_id>.pid.
+// <pidElement>.<nameElement>.<metricName>. pidElement/nameElement are
+// plugged in as-is, so callers can make either one dynamic (via
+// dynamicElement, which sets a Name) or static with a specific
+// filter value, to exercise each of CollectMetrics' dynamic branches.
+func newTestMetricType(pidElement, nameElement core.NamespaceElement, metricName, procPath string) plugin.MetricType {
+	ns := core.NewNamespace(pluginVendor, fs, pluginName).
+		AddDynamicElement("container_id", "id of the container the process belongs to").
+		AddStaticElements("pid")
+	ns = append(ns, pidElement, nameElement)
+	ns = ns.AddStaticElements(metricName)
+
+	cfg := cdata.NewNode()
+	cfg.AddItem("proc_path", ctypes.ConfigValueStr{Value: procPath})
+
+	return plugin.MetricType{
+		Namespace_: ns,
+		Config_:    cfg,
+	}
+}
+
+func dynamicElement(name string) core.NamespaceElement {
+	return core.NamespaceElement{Name: name, Value: "*"}
+}
+
+func staticElement(value string) core.NamespaceElement {
+	return core.NamespaceElement{Value: value}
+}
+
+func newTestProcPlugin(stats []Proc) *procPlugin {
+	return &procPlugin{host: "testhost", mc: &fakeProcessCollector{stats: stats}, rates: newRateTracker()}
+}
+
+func cmdNameOf(metric plugin.MetricType) string {
+	ns := metric.Namespace()
+	return ns[6].Value
+}
+
+func pidOf(metric plugin.MetricType) string {
+	ns := metric.Namespace()
+	return ns[5].Value
+}
+
+var testStats = []Proc{
+	{Pid: 1, CmdLine: "/sbin/init\x00", RSS: 100},
+	{Pid: 2, CmdLine: "/usr/sbin/nginx\x00-g\x00daemon off;", RSS: 200},
+	{Pid: 3, CmdLine: "/usr/sbin/nginx\x00-t", RSS: 300},
+}
+
+func TestCollectMetricsAllDynamic(t *testing.T) {
+	procPlg := newTestProcPlugin(testStats)
+	mt := newTestMetricType(dynamicElement("process_id"), dynamicElement("process_name"), "ps_rss", "/proc")
+
+	metrics, err := procPlg.CollectMetrics([]plugin.MetricType{mt})
+	if err != nil {
+		t.Fatalf("CollectMetrics: %v", err)
+	}
+	if len(metrics) != len(testStats) {
+		t.Fatalf("got %d metrics, want %d (one per process)", len(metrics), len(testStats))
+	}
+
+	want := map[string]string{"1": "init", "2": "nginx", "3": "nginx"}
+	for _, m := range metrics {
+		if got := cmdNameOf(m); want[pidOf(m)] != got {
+			t.Errorf("pid %s: process_name = %q, want %q", pidOf(m), got, want[pidOf(m)])
+		}
+	}
+}
+
+func TestCollectMetricsPidOnlyDynamic(t *testing.T) {
+	procPlg := newTestProcPlugin(testStats)
+	mt := newTestMetricType(dynamicElement("process_id"), staticElement("nginx"), "ps_rss", "/proc")
+
+	metrics, err := procPlg.CollectMetrics([]plugin.MetricType{mt})
+	if err != nil {
+		t.Fatalf("CollectMetrics: %v", err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("got %d metrics, want 2 (both nginx processes)", len(metrics))
+	}
+	for _, m := range metrics {
+		if pid := pidOf(m); pid != "2" && pid != "3" {
+			t.Errorf("unexpected pid %s matched the \"nginx\" name filter", pid)
+		}
+	}
+}
+
+func TestCollectMetricsNameOnlyDynamic(t *testing.T) {
+	procPlg := newTestProcPlugin(testStats)
+	mt := newTestMetricType(staticElement("2"), dynamicElement("process_name"), "ps_rss", "/proc")
+
+	metrics, err := procPlg.CollectMetrics([]plugin.MetricType{mt})
+	if err != nil {
+		t.Fatalf("CollectMetrics: %v", err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("got %d metrics, want 1 (only pid 2)", len(metrics))
+	}
+	if got := cmdNameOf(metrics[0]); got != "nginx" {
+		t.Errorf("process_name = %q, want %q", got, "nginx")
+	}
+}
+
+func TestCollectMetricsNeitherDynamic(t *testing.T) {
+	procPlg := newTestProcPlugin(testStats)
+	mt := newTestMetricType(staticElement("1"), staticElement("init"), "ps_rss", "/proc")
+
+	metrics, err := procPlg.CollectMetrics([]plugin.MetricType{mt})
+	if err != nil {
+		t.Fatalf("CollectMetrics: %v", err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("got %d metrics, want 1 (the single fully-qualified process)", len(metrics))
+	}
+	if got, want := metrics[0].Data_, uint64(100); got != want {
+		t.Errorf("ps_rss = %v, want %v", got, want)
+	}
+
+	// A pid/name pair that doesn't match any collected process yields no
+	// metric rather than an error.
+	mt = newTestMetricType(staticElement("99"), staticElement("ghost"), "ps_rss", "/proc")
+	metrics, err = procPlg.CollectMetrics([]plugin.MetricType{mt})
+	if err != nil {
+		t.Fatalf("CollectMetrics: %v", err)
+	}
+	if len(metrics) != 0 {
+		t.Errorf("got %d metrics for an unmatched pid/name, want 0", len(metrics))
+	}
+}
@@ -0,0 +1,148 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// clockTicksPerSecond is sysconf(_SC_CLK_TCK). It is effectively fixed at
+// 100 on every Linux platform this plugin targets, so unlike the other
+// /proc-derived numbers it isn't worth a cgo call to look up.
+const clockTicksPerSecond = 100
+
+// rateKey identifies a single process across collect calls. Keying on
+// starttime (not just pid) means a reused pid is treated as a brand new
+// process rather than producing a bogus rate spike.
+type rateKey struct {
+	containerID string
+	pid         int
+	startTime   uint64
+}
+
+type rateSample struct {
+	utime, stime uint64
+	rchar, wchar uint64
+	sampledAt    time.Time
+}
+
+type rateMetrics struct {
+	cpuUserPct   float64
+	cpuSystemPct float64
+	cpuTotalPct  float64
+	rcharRate    float64
+	wcharRate    float64
+}
+
+// rateTracker derives per-process CPU% and I/O byte rates by remembering
+// the previous sample for each (pid, starttime) between CollectMetrics
+// calls.
+type rateTracker struct {
+	mu      sync.Mutex
+	samples map[rateKey]rateSample
+}
+
+func newRateTracker() *rateTracker {
+	return &rateTracker{samples: map[rateKey]rateSample{}}
+}
+
+// update records proc's current counters for now and returns the rates
+// derived from the previous sample of the same process. ok is false when
+// this is the first sample seen for the process, in which case no rate can
+// be derived yet.
+func (t *rateTracker) update(containerID string, proc Proc, now time.Time) (rateMetrics, bool) {
+	key := rateKey{containerID: containerID, pid: proc.Pid, startTime: proc.StartTime}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, found := t.samples[key]
+	t.samples[key] = rateSample{
+		utime:     proc.UTime,
+		stime:     proc.STime,
+		rchar:     proc.IO.RChar,
+		wchar:     proc.IO.WChar,
+		sampledAt: now,
+	}
+
+	if !found {
+		return rateMetrics{}, false
+	}
+
+	elapsed := now.Sub(prev.sampledAt).Seconds()
+	if elapsed <= 0 {
+		return rateMetrics{}, false
+	}
+
+	numCPU := float64(runtime.NumCPU())
+	userPct := jiffyRatePct(proc.UTime, prev.utime, elapsed, numCPU)
+	systemPct := jiffyRatePct(proc.STime, prev.stime, elapsed, numCPU)
+
+	return rateMetrics{
+		cpuUserPct:   userPct,
+		cpuSystemPct: systemPct,
+		cpuTotalPct:  userPct + systemPct,
+		rcharRate:    counterRate(proc.IO.RChar, prev.rchar, elapsed),
+		wcharRate:    counterRate(proc.IO.WChar, prev.wchar, elapsed),
+	}, true
+}
+
+// evict drops every cached sample whose key wasn't seen in the most recent
+// collect, so pids that exited (or were reused by a different process,
+// which changes the starttime half of the key) don't leak memory forever.
+func (t *rateTracker) evict(live map[rateKey]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key := range t.samples {
+		if !live[key] {
+			delete(t.samples, key)
+		}
+	}
+}
+
+func jiffyRatePct(cur, prev uint64, elapsedSeconds, numCPU float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	cpuSeconds := float64(cur-prev) / clockTicksPerSecond
+	return cpuSeconds / (elapsedSeconds * numCPU) * 100
+}
+
+func counterRate(cur, prev uint64, elapsedSeconds float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / elapsedSeconds
+}
+
+// setRateMetrics converts a rateMetrics into the same
+// map[string]interface{} shape setProcMetrics produces, so the two can be
+// merged before being matched against a requested metric name.
+func setRateMetrics(rm rateMetrics) map[string]interface{} {
+	return map[string]interface{}{
+		"ps_cpu_user_pct":           rm.cpuUserPct,
+		"ps_cpu_system_pct":         rm.cpuSystemPct,
+		"ps_cpu_total_pct":          rm.cpuTotalPct,
+		"ps_disk_octets_rchar_rate": rm.rcharRate,
+		"ps_disk_octets_wchar_rate": rm.wcharRate,
+	}
+}
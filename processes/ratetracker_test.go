@@ -0,0 +1,136 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateTrackerFirstSampleIsUnknown(t *testing.T) {
+	rt := newRateTracker()
+	proc := Proc{Pid: 1, StartTime: 100, UTime: 10, STime: 5}
+
+	if _, ok := rt.update("host", proc, time.Unix(0, 0)); ok {
+		t.Fatal("the first sample for a process should not produce a rate")
+	}
+}
+
+func TestRateTrackerDerivesRateFromSecondSample(t *testing.T) {
+	rt := newRateTracker()
+	proc := Proc{Pid: 1, StartTime: 100, UTime: 10, STime: 5}
+
+	t0 := time.Unix(0, 0)
+	if _, ok := rt.update("host", proc, t0); ok {
+		t.Fatal("expected no rate on the first sample")
+	}
+
+	proc.UTime += clockTicksPerSecond
+	proc.STime += clockTicksPerSecond
+	rm, ok := rt.update("host", proc, t0.Add(time.Second))
+	if !ok {
+		t.Fatal("expected a rate on the second sample")
+	}
+
+	if rm.cpuUserPct <= 0 || rm.cpuSystemPct <= 0 {
+		t.Errorf("expected positive CPU usage, got %+v", rm)
+	}
+	if rm.cpuTotalPct != rm.cpuUserPct+rm.cpuSystemPct {
+		t.Errorf("cpuTotalPct should be the sum of user and system, got %+v", rm)
+	}
+}
+
+func TestRateTrackerPidReuseIsTreatedAsNew(t *testing.T) {
+	rt := newRateTracker()
+	t0 := time.Unix(0, 0)
+
+	first := Proc{Pid: 1, StartTime: 100, UTime: 10}
+	rt.update("host", first, t0)
+
+	// Same pid, different starttime: this is a different process, so the
+	// second sample must not be diffed against the first process's counters.
+	reused := Proc{Pid: 1, StartTime: 200, UTime: 1}
+	if _, ok := rt.update("host", reused, t0.Add(time.Second)); ok {
+		t.Error("a reused pid with a different starttime should be treated as a new process")
+	}
+}
+
+func TestRateTrackerEvict(t *testing.T) {
+	rt := newRateTracker()
+	t0 := time.Unix(0, 0)
+
+	rt.update("host", Proc{Pid: 1, StartTime: 100}, t0)
+	rt.update("host", Proc{Pid: 2, StartTime: 200}, t0)
+
+	live := map[rateKey]bool{
+		{containerID: "host", pid: 1, startTime: 100}: true,
+	}
+	rt.evict(live)
+
+	if _, ok := rt.samples[rateKey{containerID: "host", pid: 1, startTime: 100}]; !ok {
+		t.Error("a live key should not be evicted")
+	}
+	if _, ok := rt.samples[rateKey{containerID: "host", pid: 2, startTime: 200}]; ok {
+		t.Error("a key missing from the live set should be evicted")
+	}
+}
+
+func TestJiffyRatePct(t *testing.T) {
+	// 100 jiffies (1 second of CPU time) over 1 wall-clock second on a
+	// single CPU is 100% usage.
+	got := jiffyRatePct(100, 0, 1, 1)
+	if got != 100 {
+		t.Errorf("jiffyRatePct(100, 0, 1, 1) = %v, want 100", got)
+	}
+
+	// A counter that appears to go backward (e.g. after a process restart)
+	// must not produce a negative rate.
+	if got := jiffyRatePct(0, 100, 1, 1); got != 0 {
+		t.Errorf("jiffyRatePct with a decreasing counter = %v, want 0", got)
+	}
+}
+
+func TestCounterRate(t *testing.T) {
+	if got := counterRate(2000, 1000, 2); got != 500 {
+		t.Errorf("counterRate(2000, 1000, 2) = %v, want 500", got)
+	}
+
+	if got := counterRate(0, 1000, 2); got != 0 {
+		t.Errorf("counterRate with a decreasing counter = %v, want 0", got)
+	}
+}
+
+func TestSetRateMetrics(t *testing.T) {
+	rm := rateMetrics{cpuUserPct: 1, cpuSystemPct: 2, cpuTotalPct: 3, rcharRate: 4, wcharRate: 5}
+	metrics := setRateMetrics(rm)
+
+	want := map[string]interface{}{
+		"ps_cpu_user_pct":           1.0,
+		"ps_cpu_system_pct":         2.0,
+		"ps_cpu_total_pct":          3.0,
+		"ps_disk_octets_rchar_rate": 4.0,
+		"ps_disk_octets_wchar_rate": 5.0,
+	}
+	for name, val := range want {
+		if metrics[name] != val {
+			t.Errorf("metrics[%q] = %v, want %v", name, metrics[name], val)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processes
+
+// processStates maps the single-letter process state codes reported in
+// /proc/<pid>/stat to the human-readable names this plugin emits its
+// per-state process-count metrics under.
+type processStates map[string]string
+
+// States is the canonical set of process states this plugin knows about.
+var States = processStates{
+	"R": "running",
+	"S": "sleeping",
+	"D": "disk_sleep",
+	"Z": "zombie",
+	"T": "stopped",
+	"t": "tracing_stop",
+	"W": "paging",
+	"X": "dead",
+	"I": "idle",
+}
+
+// Values returns the human-readable state names, in no particular order.
+func (p processStates) Values() []string {
+	values := make([]string, 0, len(p))
+	for _, name := range p {
+		values = append(values, name)
+	}
+	return values
+}